@@ -0,0 +1,45 @@
+package actor
+
+import (
+	"io"
+
+	"github.com/fnproject/flow/blobs"
+)
+
+// defaultUploadChunkSize is used when streaming a FaaS response into blob
+// storage via a chunked upload session, in the absence of WithUploadChunkSize.
+const defaultUploadChunkSize = 4 * 1024 * 1024 // 4MiB
+
+// maxChunkRetries bounds the attempts (the first write included) at writing a
+// single chunk before streamToUploadSession gives up.
+const maxChunkRetries = 3
+
+// streamToUploadSession copies src into session in chunkSize pieces without
+// buffering the full body.
+func streamToUploadSession(session blobs.UploadSession, src io.Reader, chunkSize int) error {
+	buf := make([]byte, chunkSize)
+	for {
+		n, readErr := io.ReadFull(src, buf)
+		if n > 0 {
+			if err := writeChunkWithRetry(session, buf[:n]); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+func writeChunkWithRetry(session blobs.UploadSession, chunk []byte) error {
+	var err error
+	for attempt := 0; attempt < maxChunkRetries; attempt++ {
+		if _, err = session.Write(chunk); err == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package actor
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StageCodec marshals and unmarshals the RuntimeInvokeStageRequest/Response
+// exchanged with a function's runtime, letting operators trade the
+// readability of JSON for the lower marshalling cost of raw protobuf on a
+// per-function basis.
+type StageCodec interface {
+	ContentType() string
+	Marshal(proto.Message) ([]byte, error)
+	Unmarshal([]byte, proto.Message) error
+}
+
+var stageCodecBytesMetric = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "flow_stage_codec_bytes",
+	Help:    "Size in bytes of stage request/response payloads, labeled by codec content type.",
+	Buckets: prometheus.ExponentialBuckets(64, 4, 10),
+}, []string{"codec"})
+
+func init() {
+	prometheus.MustRegister(stageCodecBytesMetric)
+}
+
+// jsonStageCodec is the original application/json behavior, using jsonpb so
+// proto3 field names and defaults are handled the way the FaaS runtimes expect.
+type jsonStageCodec struct{}
+
+func (jsonStageCodec) ContentType() string { return "application/json" }
+
+func (jsonStageCodec) Marshal(msg proto.Message) ([]byte, error) {
+	writer := jsonpb.Marshaler{EmitDefaults: true, OrigName: true}
+	var buf bytes.Buffer
+	if err := writer.Marshal(&buf, msg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (jsonStageCodec) Unmarshal(data []byte, msg proto.Message) error {
+	reader := jsonpb.Unmarshaler{AllowUnknownFields: true}
+	return reader.Unmarshal(bytes.NewReader(data), msg)
+}
+
+// protobufStageCodec sends the wire-format protobuf encoding directly, which
+// is cheaper to marshal/unmarshal than JSON for large closures and arguments.
+type protobufStageCodec struct{}
+
+func (protobufStageCodec) ContentType() string { return "application/x-protobuf" }
+
+func (protobufStageCodec) Marshal(msg proto.Message) ([]byte, error) {
+	return proto.Marshal(msg)
+}
+
+func (protobufStageCodec) Unmarshal(data []byte, msg proto.Message) error {
+	return proto.Unmarshal(data, msg)
+}
+
+// JSONStageCodec and ProtobufStageCodec are the built-in StageCodec implementations.
+var (
+	JSONStageCodec     StageCodec = jsonStageCodec{}
+	ProtobufStageCodec StageCodec = protobufStageCodec{}
+)
+
+// buildAcceptHeader renders codecs as an Accept header, preferred first at
+// q=1 and the rest at q=0.5.
+func buildAcceptHeader(preferred StageCodec, codecs []StageCodec) string {
+	parts := []string{preferred.ContentType()}
+	for _, c := range codecs {
+		if c.ContentType() == preferred.ContentType() {
+			continue
+		}
+		parts = append(parts, c.ContentType()+";q=0.5")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// codecForFunction resolves the StageCodec to use when calling functionID:
+// the per-function override if one is configured, otherwise the executor's
+// preferred codec.
+func (exec *graphExecutor) codecForFunction(functionID string) StageCodec {
+	if codec, ok := exec.functionCodecOverrides[functionID]; ok {
+		return codec
+	}
+	return exec.preferredCodec
+}
+
+// codecForContentType resolves the StageCodec matching a response's
+// Content-Type for functionID, checking that function's override before the
+// executor's enabled codecs, falling back to the preferred codec.
+func (exec *graphExecutor) codecForContentType(functionID, contentType string) StageCodec {
+	contentType = strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	if codec, ok := exec.functionCodecOverrides[functionID]; ok && codec.ContentType() == contentType {
+		return codec
+	}
+	for _, c := range exec.codecs {
+		if c.ContentType() == contentType {
+			return c
+		}
+	}
+	return exec.preferredCodec
+}
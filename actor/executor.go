@@ -1,25 +1,41 @@
+// Package actor implements the executor side of the flow graph engine. It
+// depends on the TraceContext/Attempt/ReplayMode fields of
+// model.InvokeStageRequest / model.InvokeFunctionRequest and on the chunked
+// upload session methods of blobs.Store; those live in the model and blobs
+// packages respectively and are versioned together with this package.
 package actor
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/textproto"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/AsynkronIT/protoactor-go/actor"
 	"github.com/fnproject/flow/blobs"
 	"github.com/fnproject/flow/model"
 	"github.com/fnproject/flow/protocol"
-	"github.com/golang/protobuf/jsonpb"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const fnCallIDHeader = "Fn_call_id"
 
+// instrumentationName identifies this package as a trace/metric source, following
+// the convention used by otel instrumentation libraries.
+const instrumentationName = "github.com/fnproject/flow/actor"
+
 var (
 	activeFnCallsMetric = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "flow_concurrent_active_fn_calls",
@@ -33,10 +49,34 @@ func init() {
 }
 
 type graphExecutor struct {
-	faasAddr  string
-	client    httpClient
-	blobStore blobs.Store
-	log       *logrus.Entry
+	faasAddr        string
+	client          httpClient
+	newWorkerClient func() httpClient
+	blobStore       blobs.Store
+	log             *logrus.Entry
+	tracer          trace.Tracer
+	propagator      propagation.TextMapPropagator
+	interceptors    []Interceptor
+	stageTimeout    time.Duration
+	uploadChunkSize int
+	poolSize        int
+	queueDepth      int
+
+	codecs                 []StageCodec
+	preferredCodec         StageCodec
+	functionCodecOverrides map[string]StageCodec
+
+	journal        Journal
+	journalLocksMu sync.Mutex
+	journalLocks   map[JournalKey]*journalMutex
+
+	rootCtx    context.Context
+	rootCancel context.CancelFunc
+	queue      chan poolTask
+	queueMu    sync.Mutex // guards stopped/queue against a concurrent stopWorkerPool close
+	stopped    bool
+	busyCount  int32
+	wg         sync.WaitGroup
 }
 
 // For mocking
@@ -51,120 +91,328 @@ type ExecHandler interface {
 	HandleInvokeFunction(msg *model.InvokeFunctionRequest) *model.FaasInvocationResponse
 }
 
-// NewExecutor creates a new executor actor with the given funcions service endpoint
-func NewExecutor(faasAddress string, blobStore blobs.Store) actor.Actor {
-	client := &http.Client{}
-	// TODO configure timeouts
-	client.Timeout = 300 * time.Second
+// defaultStageTimeout bounds the context passed into a stage invocation (and hence
+// its retries) when the caller does not derive one from the stage's own budget.
+const defaultStageTimeout = 300 * time.Second
+
+// NewExecutor creates a new executor actor with the given funcions service endpoint.
+// Spans are recorded against the global OpenTelemetry tracer provider; use
+// NewExecutorWithTracer to supply one explicitly (e.g. in tests). opts configure
+// interceptors, upload chunking and other optional behavior.
+func NewExecutor(faasAddress string, blobStore blobs.Store, opts ...ExecutorOption) actor.Actor {
+	return NewExecutorWithTracer(otel.GetTracerProvider(), faasAddress, blobStore, opts...)
+}
 
-	return &graphExecutor{faasAddr: faasAddress,
-		log:       logrus.WithField("logger", "executor_actor").WithField("faas_url", faasAddress),
-		client:    client,
-		blobStore: blobStore,
+// NewExecutorWithTracer creates a new executor actor that derives spans from tp instead
+// of the global tracer provider. This lets the service boot code wire up a concrete
+// OTLP/gRPC, OTLP/HTTP or Zipkin exporter and pass its provider straight through, and
+// lets tests assert on recorded spans without touching global state.
+func NewExecutorWithTracer(tp trace.TracerProvider, faasAddress string, blobStore blobs.Store, opts ...ExecutorOption) actor.Actor {
+	client := &http.Client{}
+	// Timeouts are enforced via the per-stage context deadline instead (see
+	// defaultStageTimeout), so retries can see ctx.Done() and stop retrying.
+
+	rootCtx, rootCancel := context.WithCancel(context.Background())
+
+	exec := &graphExecutor{faasAddr: faasAddress,
+		log:             logrus.WithField("logger", "executor_actor").WithField("faas_url", faasAddress),
+		client:          client,
+		newWorkerClient: func() httpClient { return &http.Client{} },
+		blobStore:       blobStore,
+		tracer:          tp.Tracer(instrumentationName),
+		propagator:      propagation.TraceContext{},
+		stageTimeout:    defaultStageTimeout,
+		uploadChunkSize: defaultUploadChunkSize,
+		poolSize:        defaultPoolSize,
+		queueDepth:      defaultQueueDepth,
+		codecs:          []StageCodec{JSONStageCodec},
+		preferredCodec:  JSONStageCodec,
+		journal:         noopJournal{},
+		journalLocks:    make(map[JournalKey]*journalMutex),
+		rootCtx:         rootCtx,
+		rootCancel:      rootCancel,
+	}
+	for _, opt := range opts {
+		opt(exec)
 	}
+	return exec
 }
 
-func (exec *graphExecutor) Receive(context actor.Context) {
-	sender := context.Sender()
-	switch msg := context.Message().(type) {
+func (exec *graphExecutor) Receive(actorCtx actor.Context) {
+	sender := actorCtx.Sender()
+	switch msg := actorCtx.Message().(type) {
 	case *actor.Started:
 		exec.log.Info("Started executor actor")
+		exec.startWorkerPool()
+	case *actor.Stopping:
+		exec.log.Info("Stopping executor actor, draining work queue")
+		exec.stopWorkerPool()
 	case *model.InvokeStageRequest:
-		go func() { sender.Tell(exec.HandleInvokeStage(msg)) }()
+		exec.dispatch(msg, sender)
 	case *model.InvokeFunctionRequest:
-		go func() { sender.Tell(exec.HandleInvokeFunction(msg)) }()
+		exec.dispatch(msg, sender)
 	}
 }
 
+// spanContextFromCarrier derives a context carrying the remote span described by
+// carrier (the W3C traceparent/tracestate pair propagated on the inbound message),
+// falling back to the executor's root context (cancelled by stopWorkerPool) when
+// the message carries none.
+func (exec *graphExecutor) spanContextFromCarrier(carrier map[string]string) context.Context {
+	root := exec.rootCtx
+	if root == nil {
+		root = context.Background()
+	}
+	if exec.propagator == nil {
+		return root
+	}
+	return exec.propagator.Extract(root, propagation.MapCarrier(carrier))
+}
+
+func (exec *graphExecutor) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	if exec.tracer == nil {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return exec.tracer.Start(ctx, name)
+}
+
+// HandleInvokeStage implements ExecHandler using the executor's shared HTTP client.
+// Inbound actor messages are instead routed through the worker pool (see pool.go),
+// where each worker owns its own client for connection reuse.
 func (exec *graphExecutor) HandleInvokeStage(msg *model.InvokeStageRequest) *model.FaasInvocationResponse {
+	return exec.handleInvokeStage(msg, exec.client)
+}
+
+func (exec *graphExecutor) handleInvokeStage(msg *model.InvokeStageRequest, client httpClient) *model.FaasInvocationResponse {
 	stageLog := exec.log.WithFields(logrus.Fields{"flow_id": msg.FlowId, "stage_id": msg.StageId, "function_id": msg.FunctionId})
 	stageLog.Info("Running Stage")
 
+	ctx, span := exec.startSpan(exec.spanContextFromCarrier(msg.GetTraceContext()), "flow.invoke_stage")
+	span.SetAttributes(
+		attribute.String("flow.id", msg.FlowId),
+		attribute.String("stage.id", msg.StageId),
+		attribute.String("function.id", msg.FunctionId),
+	)
+	defer span.End()
+
+	journalKey := journalKeyForStage(msg)
+	// Serialize lookup-invoke-record for a given key so two concurrent
+	// deliveries of the same attempt (e.g. across worker-pool goroutines
+	// after a crash-restart re-drive) can't both miss the journal and
+	// invoke the function at once.
+	unlockJournalKey := exec.lockJournalKey(journalKey)
+	defer unlockJournalKey()
+
+	if journaled, ok := exec.journal.Lookup(journalKey); ok {
+		span.SetAttributes(attribute.Bool("flow.journal_hit", true))
+		stageLog.Info("Returning journaled stage response without re-invoking function")
+		return journaled
+	}
+	if msg.GetReplayMode() {
+		stageLog.Error("Replay mode requested but no journaled response exists for this attempt")
+		return exec.stageFailedSpan(span, msg, model.ErrorDatumType_stage_failed, "No journaled response available for replay", "")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, exec.stageTimeout)
+	defer cancel()
+
 	runtimeRequest := &model.RuntimeInvokeStageRequest{
 		FlowId:  msg.GetFlowId(),
 		StageId: msg.GetStageId(),
 		Args:    msg.GetArgs(),
 		Closure: msg.GetClosure(),
 	}
-	buf := new(bytes.Buffer)
-	writer := jsonpb.Marshaler{EmitDefaults: true, OrigName: true}
-	err := writer.Marshal(buf, runtimeRequest)
+	codec := exec.codecForFunction(msg.FunctionId)
+	payload, err := codec.Marshal(runtimeRequest)
 	if err != nil {
-		return stageFailed(msg, model.ErrorDatumType_stage_failed, "Could not marshal the runtime invoke stage request message.", "")
+		return exec.stageFailedSpan(span, msg, model.ErrorDatumType_stage_failed, "Could not marshal the runtime invoke stage request message.", "")
 	}
+	stageCodecBytesMetric.WithLabelValues(codec.ContentType()).Observe(float64(len(payload)))
 
-	req, _ := http.NewRequest("POST", exec.faasAddr+"/"+msg.FunctionId, buf)
-	req.Header.Set("Content-type", "application/json")
+	req, _ := http.NewRequestWithContext(ctx, "POST", exec.faasAddr+"/"+msg.FunctionId, bytes.NewReader(payload))
+	req.Header.Set("Content-type", codec.ContentType())
+	req.Header.Set("Accept", buildAcceptHeader(codec, exec.codecs))
 	req.Header.Set(protocol.HeaderFlowID, msg.FlowId)
 	req.Header.Set(protocol.HeaderStageRef, msg.StageId)
+	if exec.propagator != nil {
+		exec.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	}
 
 	activeFnCallsMetric.Inc()
 	defer activeFnCallsMetric.Dec()
-	resp, err := exec.client.Do(req)
+
+	invoke := chainInterceptors(msg.FunctionId, exec.interceptors, func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return client.Do(req)
+	})
+	resp, err := invoke(ctx, req)
 
 	if err != nil {
-		return stageFailed(msg, model.ErrorDatumType_stage_failed, "HTTP error on stage invocation: Can the flow service talk to the functions server?", "")
+		span.SetStatus(codes.Error, "HTTP error on stage invocation")
+		if exec.rootCtx.Err() != nil {
+			// The executor is shutting down: rootCtx (which ctx was derived from)
+			// was cancelled out from under this invocation, whether it was still
+			// queued or already in flight, so this isn't a genuine transport failure.
+			// Surface it as a timeout rather than a hard failure so the graph
+			// engine resumes the stage from snapshot instead of treating it as
+			// permanently failed.
+			stageLog.Warn("Executor shutting down; failing in-flight stage invocation as a timeout so it can be resumed from snapshot")
+			return exec.recordFailureAndReturn(span, msg, journalKey, model.ErrorDatumType_stage_timeout, "Executor is shutting down", "")
+		}
+		// A transport error (timeout, connection reset) is the ambiguous case a
+		// replay journal exists for: the function may already have executed, so
+		// this outcome must be journaled just like the non-200/unmarshal paths
+		// below, or a retry of this attempt will miss the journal and re-invoke it.
+		return exec.recordFailureAndReturn(span, msg, journalKey, model.ErrorDatumType_stage_failed, "HTTP error on stage invocation: Can the flow service talk to the functions server?", "")
 	}
 	defer resp.Body.Close()
 
 	lbDelayHeader := resp.Header.Get("Xxx-Fxlb-Wait")
 	callID := resp.Header.Get(fnCallIDHeader)
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode), attribute.String("fn_call_id", callID))
+	if waitMs, err := strconv.Atoi(lbDelayHeader); err == nil {
+		span.SetAttributes(attribute.Int64("fxlb.wait_ms", int64(waitMs)))
+	}
 
 	if !exec.successfulResponse(resp) {
 		stageLog.WithField("fn_call_id", callID).WithField("fn_lb_delay", lbDelayHeader).WithField("http_status", fmt.Sprintf("%d", resp.StatusCode)).Error("Got non-200 error from FaaS endpoint")
+		span.SetStatus(codes.Error, fmt.Sprintf("non-200 response: %d", resp.StatusCode))
 
 		if resp.StatusCode == 504 {
-			return &model.FaasInvocationResponse{FlowId: msg.FlowId, StageId: msg.StageId, FunctionId: msg.FunctionId, Result: model.NewInternalErrorResult(model.ErrorDatumType_stage_timeout, "stage timed out"), CallId: callID}
+			return exec.recordAndReturn(span, msg, journalKey, &model.FaasInvocationResponse{FlowId: msg.FlowId, StageId: msg.StageId, FunctionId: msg.FunctionId, Result: model.NewInternalErrorResult(model.ErrorDatumType_stage_timeout, "stage timed out"), CallId: callID})
 		}
-		return stageFailed(msg, model.ErrorDatumType_stage_failed, fmt.Sprintf("Invalid http response from functions platform code %d", resp.StatusCode), callID)
+		return exec.recordFailureAndReturn(span, msg, journalKey, model.ErrorDatumType_stage_failed, fmt.Sprintf("Invalid http response from functions platform code %d", resp.StatusCode), callID)
 	}
 
 	runtimeResponse := &model.RuntimeInvokeStageResponse{}
-	reader := jsonpb.Unmarshaler{AllowUnknownFields: true}
-	err = reader.Unmarshal(resp.Body, runtimeResponse)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		stageLog.WithField("fn_call_id", callID).WithField("fn_lb_delay", lbDelayHeader).Error("Failed to read result from functions service: ", err)
-		return stageFailed(msg, model.ErrorDatumType_invalid_stage_response, "Failed to read result from functions service", callID)
+		return exec.recordFailureAndReturn(span, msg, journalKey, model.ErrorDatumType_invalid_stage_response, "Failed to read result from functions service", callID)
+	}
+	respCodec := exec.codecForContentType(msg.FunctionId, resp.Header.Get("Content-Type"))
+	stageCodecBytesMetric.WithLabelValues(respCodec.ContentType()).Observe(float64(len(respBody)))
+	if err := respCodec.Unmarshal(respBody, runtimeResponse); err != nil {
+		stageLog.WithField("fn_call_id", callID).WithField("fn_lb_delay", lbDelayHeader).Error("Failed to read result from functions service: ", err)
+		return exec.recordFailureAndReturn(span, msg, journalKey, model.ErrorDatumType_invalid_stage_response, "Failed to read result from functions service", callID)
 	}
 
 	result := runtimeResponse.GetResult()
 	if result == nil {
 		stageLog.WithField("fn_call_id", callID).WithField("fn_lb_delay", lbDelayHeader).Error("Empty result from functions service")
-		return stageFailed(msg, model.ErrorDatumType_invalid_stage_response, "Empty result from functions service", callID)
+		return exec.recordFailureAndReturn(span, msg, journalKey, model.ErrorDatumType_invalid_stage_response, "Empty result from functions service", callID)
 	}
 
 	stageLog.WithField("fn_call_id", callID).WithField("fn_lb_delay", lbDelayHeader).WithField("successful", fmt.Sprintf("%t", result.Successful)).Info("Got stage response")
+	if !result.Successful {
+		span.SetStatus(codes.Error, "stage completed with a failure result")
+	}
 
-	return &model.FaasInvocationResponse{FlowId: msg.FlowId, StageId: msg.StageId, FunctionId: msg.FunctionId, Result: result, CallId: callID}
+	return exec.recordAndReturn(span, msg, journalKey, &model.FaasInvocationResponse{FlowId: msg.FlowId, StageId: msg.StageId, FunctionId: msg.FunctionId, Result: result, CallId: callID})
+}
+
+// recordAndReturn journals resp under key before returning it, so a later
+// retry of the same attempt resolves from the journal instead of
+// re-invoking the function. Fails the stage if the journal write itself
+// errors, rather than hand back a success with no durable record behind it.
+func (exec *graphExecutor) recordAndReturn(span trace.Span, msg *model.InvokeStageRequest, key JournalKey, resp *model.FaasInvocationResponse) *model.FaasInvocationResponse {
+	if err := exec.journal.Record(key, resp); err != nil {
+		exec.log.WithError(err).Error("Failed to durably record stage invocation outcome; failing stage rather than risk a double-invoke on retry")
+		return exec.stageFailedSpan(span, msg, model.ErrorDatumType_stage_failed, "Failed to durably record stage outcome", resp.CallId)
+	}
+	return resp
+}
+
+// recordFailureAndReturn journals a failure response before returning it, so a
+// later replay-mode lookup for this attempt sees the original error instead of
+// a generic "no journaled response" failure.
+func (exec *graphExecutor) recordFailureAndReturn(span trace.Span, msg *model.InvokeStageRequest, key JournalKey, errorType model.ErrorDatumType, errorMessage string, callID string) *model.FaasInvocationResponse {
+	return exec.recordAndReturn(span, msg, key, exec.stageFailedSpan(span, msg, errorType, errorMessage, callID))
+}
+
+// journalMutex is a per-key entry in journalLocks, evicted once waiters
+// drops to zero; every access goes through journalLocksMu so insert/evict
+// can't race a concurrent lookup for the same key.
+type journalMutex struct {
+	mu      sync.Mutex
+	waiters int
+}
+
+// lockJournalKey serializes lookup-invoke-record for key across concurrent
+// worker goroutines, returning an unlock function to defer.
+func (exec *graphExecutor) lockJournalKey(key JournalKey) func() {
+	exec.journalLocksMu.Lock()
+	jm, ok := exec.journalLocks[key]
+	if !ok {
+		jm = &journalMutex{}
+		exec.journalLocks[key] = jm
+	}
+	jm.waiters++
+	exec.journalLocksMu.Unlock()
+
+	jm.mu.Lock()
+	return func() {
+		jm.mu.Unlock()
+		exec.journalLocksMu.Lock()
+		jm.waiters--
+		if jm.waiters == 0 {
+			delete(exec.journalLocks, key)
+		}
+		exec.journalLocksMu.Unlock()
+	}
+}
+
+// stageFailedSpan marks span as failed and builds the corresponding error response.
+func (exec *graphExecutor) stageFailedSpan(span trace.Span, msg *model.InvokeStageRequest, errorType model.ErrorDatumType, errorMessage string, callID string) *model.FaasInvocationResponse {
+	span.SetStatus(codes.Error, errorMessage)
+	return stageFailed(msg, errorType, errorMessage, callID)
 }
 
 func stageFailed(msg *model.InvokeStageRequest, errorType model.ErrorDatumType, errorMessage string, callID string) *model.FaasInvocationResponse {
 	return &model.FaasInvocationResponse{FlowId: msg.FlowId, StageId: msg.StageId, FunctionId: msg.FunctionId, Result: model.NewInternalErrorResult(errorType, errorMessage), CallId: callID}
 }
 
+// HandleInvokeFunction implements ExecHandler using the executor's shared HTTP client.
+// Inbound actor messages are instead routed through the worker pool (see pool.go).
 func (exec *graphExecutor) HandleInvokeFunction(msg *model.InvokeFunctionRequest) *model.FaasInvocationResponse {
+	return exec.handleInvokeFunction(msg, exec.client)
+}
+
+func (exec *graphExecutor) handleInvokeFunction(msg *model.InvokeFunctionRequest, client httpClient) *model.FaasInvocationResponse {
 	datum := msg.Arg
 
 	method := strings.ToUpper(model.HTTPMethod_name[int32(datum.Method)])
 	stageLog := exec.log.WithFields(logrus.Fields{"flow_id": msg.FlowId, "stage_id": msg.StageId, "target_function_id": msg.FunctionId, "method": method})
 	stageLog.Info("Sending function invocation")
 
+	ctx, span := exec.startSpan(exec.spanContextFromCarrier(msg.GetTraceContext()), "flow.invoke_function")
+	span.SetAttributes(
+		attribute.String("flow.id", msg.FlowId),
+		attribute.String("stage.id", msg.StageId),
+		attribute.String("function.id", msg.FunctionId),
+	)
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, exec.stageTimeout)
+	defer cancel()
+
 	var bodyReader io.Reader
 
 	if datum.Body != nil {
 		var err error
-		bodyReader, err = exec.blobStore.Read(msg.FlowId, datum.Body.BlobId)
+		bodyReader, err = exec.blobStore.OpenRange(msg.FlowId, datum.Body.BlobId, 0, -1)
 		if err != nil {
 			stageLog.WithError(err).Warn("Failed to fetch blob from store")
+			span.SetStatus(codes.Error, "failed to read data for invocation")
 			return exec.invokeFailed(msg, "Failed to read data for invocation", "")
 		}
 	} else {
 		bodyReader = http.NoBody
 	}
 
-	req, err := http.NewRequest(strings.ToUpper(method), exec.faasAddr+"/"+msg.FunctionId, bodyReader)
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), exec.faasAddr+"/"+msg.FunctionId, bodyReader)
 	if err != nil {
 		exec.log.Error("Failed to create http request:", err)
+		span.SetStatus(codes.Error, "failed to create HTTP request")
 		return exec.invokeFailed(msg, "Failed to create HTTP request", "")
 	}
 
@@ -176,12 +424,24 @@ func (exec *graphExecutor) HandleInvokeFunction(msg *model.InvokeFunctionRequest
 		req.Header.Add(header.Key, header.Value)
 	}
 
+	if exec.propagator != nil {
+		exec.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	}
+
 	activeFnCallsMetric.Inc()
 	defer activeFnCallsMetric.Dec()
-	resp, err := exec.client.Do(req)
+	invoke := chainInterceptors(msg.FunctionId, exec.interceptors, func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return client.Do(req)
+	})
+	resp, err := invoke(ctx, req)
 
 	if err != nil {
 		exec.log.Error("Http error calling functions service:", err)
+		span.SetStatus(codes.Error, "failed to call function")
+		if exec.rootCtx.Err() != nil {
+			// Shutting down: report a timeout, as handleInvokeStage does.
+			return &model.FaasInvocationResponse{FlowId: msg.FlowId, StageId: msg.StageId, FunctionId: msg.FunctionId, Result: model.NewInternalErrorResult(model.ErrorDatumType_stage_timeout, "Executor is shutting down")}
+		}
 		return exec.invokeFailed(msg, "Failed to call function", "")
 
 	}
@@ -190,11 +450,15 @@ func (exec *graphExecutor) HandleInvokeFunction(msg *model.InvokeFunctionRequest
 	lbDelayHeader := resp.Header.Get("Xxx-Fxlb-Wait")
 	if len(lbDelayHeader) > 0 {
 		stageLog.WithField("fn_lb_delay", lbDelayHeader).Info("Fn load balancer delay")
+		if waitMs, err := strconv.Atoi(lbDelayHeader); err == nil {
+			span.SetAttributes(attribute.Int64("fxlb.wait_ms", int64(waitMs)))
+		}
 	} else {
 		stageLog.Info("No Fn load balancer delay header received")
 	}
 
 	callID := resp.Header.Get(fnCallIDHeader)
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode), attribute.String("fn_call_id", callID))
 
 	var contentType = resp.Header.Get("Content-type")
 	if contentType == "" {
@@ -211,9 +475,21 @@ func (exec *graphExecutor) HandleInvokeFunction(msg *model.InvokeFunctionRequest
 		}
 	}
 
-	blob, err := exec.blobStore.Create(msg.FlowId, contentType, resp.Body)
+	uploadSession, err := exec.blobStore.StartUpload(msg.FlowId, contentType)
 	if err != nil {
-		stageLog.WithError(err).Warn("failed to persist data in blob store")
+		stageLog.WithError(err).Warn("failed to start chunked blob upload")
+		span.SetStatus(codes.Error, "failed to start chunked blob upload")
+		return exec.invokeFailed(msg, "Failed to persist HTTP response data", callID)
+	}
+	if err := streamToUploadSession(uploadSession, resp.Body, exec.uploadChunkSize); err != nil {
+		stageLog.WithError(err).Warn("failed to stream data into blob store")
+		span.SetStatus(codes.Error, "failed to persist HTTP response data")
+		return exec.invokeFailed(msg, "Failed to persist HTTP response data", callID)
+	}
+	blob, err := uploadSession.Commit()
+	if err != nil {
+		stageLog.WithError(err).Warn("failed to commit chunked blob upload")
+		span.SetStatus(codes.Error, "failed to persist HTTP response data")
 		return exec.invokeFailed(msg, "Failed to persist HTTP response data", callID)
 	}
 
@@ -224,7 +500,11 @@ func (exec *graphExecutor) HandleInvokeFunction(msg *model.InvokeFunctionRequest
 				Body:       model.BlobDatumFromBlobStoreBlob(blob),
 				StatusCode: int32(resp.StatusCode)}}}
 
-	result := &model.CompletionResult{Successful: exec.successfulResponse(resp), Datum: resultDatum}
+	successful := exec.successfulResponse(resp)
+	if !successful {
+		span.SetStatus(codes.Error, fmt.Sprintf("non-200 response: %d", resp.StatusCode))
+	}
+	result := &model.CompletionResult{Successful: successful, Datum: resultDatum}
 	return &model.FaasInvocationResponse{FlowId: msg.FlowId, StageId: msg.StageId, FunctionId: msg.FunctionId, Result: result, CallId: callID}
 }
 
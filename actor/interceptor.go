@@ -0,0 +1,195 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// InvokeFunc performs a single attempt at the outbound HTTP call for a stage
+// or function invocation. It is the innermost link in an Interceptor chain.
+type InvokeFunc func(ctx context.Context, req *http.Request) (*http.Response, error)
+
+// Interceptor wraps an outbound FaaS call, analogous to a YARPC unary outbound
+// filter: it can retry, rate-limit, trip a breaker or otherwise decorate next
+// before returning its result to the caller. functionID keys any per-function
+// state (semaphores, breakers) since it's the one thing stage and raw
+// function invocations share.
+type Interceptor func(ctx context.Context, functionID string, next InvokeFunc) InvokeFunc
+
+// chainInterceptors composes interceptors around terminal, with the first
+// interceptor in the slice as the outermost wrapper.
+func chainInterceptors(functionID string, interceptors []Interceptor, terminal InvokeFunc) InvokeFunc {
+	next := terminal
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		next = interceptors[i](context.Background(), functionID, next)
+	}
+	return next
+}
+
+// BackoffConfig configures the retry interceptor's exponential-backoff-with-
+// full-jitter schedule, as described in
+// https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/.
+type BackoffConfig struct {
+	Base        time.Duration
+	Cap         time.Duration
+	MaxAttempts int
+}
+
+// DefaultBackoffConfig is used when the service boot code does not configure one explicitly.
+var DefaultBackoffConfig = BackoffConfig{Base: 100 * time.Millisecond, Cap: 5 * time.Second, MaxAttempts: 5}
+
+func isRetryableStatus(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// fullJitterBackoff returns a uniformly random duration between 0 and
+// min(cap, base*2^attempt) - the "full jitter" strategy.
+func fullJitterBackoff(cfg BackoffConfig, attempt int) time.Duration {
+	exp := float64(cfg.Base) * math.Pow(2, float64(attempt))
+	if exp > float64(cfg.Cap) {
+		exp = float64(cfg.Cap)
+	}
+	if exp <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(exp) + 1))
+}
+
+// retryAfterDelay parses a Retry-After header (seconds form only, which is what
+// the functions service emits) and reports whether one was present.
+func retryAfterDelay(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	raw := resp.Header.Get("Retry-After")
+	if raw == "" {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// RetryInterceptor retries the outbound call on transient 5xx responses and
+// network errors using exponential backoff with full jitter, honoring a
+// Retry-After header when present, and stops once ctx's deadline is
+// exhausted. Never retries a request whose body it can't rewind via
+// req.GetBody (nil for a body built from an arbitrary io.Reader, e.g. the
+// stream handleInvokeFunction reads from blobStore.OpenRange).
+func RetryInterceptor(cfg BackoffConfig) Interceptor {
+	return func(_ context.Context, _ string, next InvokeFunc) InvokeFunc {
+		return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			canRewindBody := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+			var resp *http.Response
+			var err error
+			for attempt := 0; attempt < cfg.MaxAttempts; attempt++ {
+				if attempt > 0 && req.GetBody != nil {
+					body, bodyErr := req.GetBody()
+					if bodyErr != nil {
+						return resp, bodyErr
+					}
+					req.Body = body
+				}
+
+				resp, err = next(ctx, req)
+				if err == nil && !isRetryableStatus(resp.StatusCode) {
+					return resp, nil
+				}
+				if attempt == cfg.MaxAttempts-1 || !canRewindBody {
+					break
+				}
+
+				delay := fullJitterBackoff(cfg, attempt)
+				if afterDelay, ok := retryAfterDelay(resp); ok {
+					delay = afterDelay
+				}
+				if resp != nil {
+					resp.Body.Close()
+				}
+
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			}
+			return resp, err
+		}
+	}
+}
+
+// ConcurrencyLimitInterceptor caps the number of in-flight calls to any single
+// function ID, queueing excess callers on a per-function semaphore.
+func ConcurrencyLimitInterceptor(maxInFlight int) Interceptor {
+	var semaphores sync.Map // functionID -> chan struct{}
+	acquireChan := func(functionID string) chan struct{} {
+		sem, _ := semaphores.LoadOrStore(functionID, make(chan struct{}, maxInFlight))
+		return sem.(chan struct{})
+	}
+
+	return func(_ context.Context, functionID string, next InvokeFunc) InvokeFunc {
+		return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			sem := acquireChan(functionID)
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			defer func() { <-sem }()
+			return next(ctx, req)
+		}
+	}
+}
+
+// errCircuitOpen is returned by CircuitBreakerInterceptor while a function's
+// breaker is tripped.
+var errCircuitOpen = errors.New("circuit breaker open for function")
+
+type circuitState struct {
+	mu                sync.Mutex
+	consecutiveErrors int
+	openUntil         time.Time
+}
+
+// CircuitBreakerInterceptor trips per function ID after maxConsecutiveFailures
+// transient failures in a row, short-circuiting further calls to that function
+// for openDuration so a misbehaving function cannot saturate activeFnCallsMetric.
+func CircuitBreakerInterceptor(maxConsecutiveFailures int, openDuration time.Duration) Interceptor {
+	var breakers sync.Map // functionID -> *circuitState
+
+	return func(_ context.Context, functionID string, next InvokeFunc) InvokeFunc {
+		return func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			v, _ := breakers.LoadOrStore(functionID, &circuitState{})
+			state := v.(*circuitState)
+
+			state.mu.Lock()
+			if time.Now().Before(state.openUntil) {
+				state.mu.Unlock()
+				return nil, errCircuitOpen
+			}
+			state.mu.Unlock()
+
+			resp, err := next(ctx, req)
+
+			state.mu.Lock()
+			defer state.mu.Unlock()
+			if err != nil || isRetryableStatus(resp.StatusCode) {
+				state.consecutiveErrors++
+				if state.consecutiveErrors >= maxConsecutiveFailures {
+					state.openUntil = time.Now().Add(openDuration)
+				}
+			} else {
+				state.consecutiveErrors = 0
+			}
+			return resp, err
+		}
+	}
+}
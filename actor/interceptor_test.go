@@ -0,0 +1,84 @@
+package actor
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFullJitterBackoffBounds(t *testing.T) {
+	cfg := BackoffConfig{Base: 100 * time.Millisecond, Cap: time.Second, MaxAttempts: 10}
+	for attempt := 0; attempt < 6; attempt++ {
+		max := time.Duration(float64(cfg.Base) * math.Pow(2, float64(attempt)))
+		if max > cfg.Cap {
+			max = cfg.Cap
+		}
+		for i := 0; i < 50; i++ {
+			if d := fullJitterBackoff(cfg, attempt); d < 0 || d > max {
+				t.Fatalf("attempt %d: backoff %v outside [0, %v]", attempt, d, max)
+			}
+		}
+	}
+}
+
+func newTestResponse(status int) *http.Response {
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(""))}
+}
+
+func TestCircuitBreakerInterceptorTripsAndResets(t *testing.T) {
+	const openDuration = 20 * time.Millisecond
+	breaker := CircuitBreakerInterceptor(2, openDuration)
+	req, _ := http.NewRequest("POST", "http://example.invalid", nil)
+
+	failing := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return newTestResponse(http.StatusBadGateway), nil
+	}
+	chain := breaker(context.Background(), "fn-1", failing)
+	for i := 0; i < 2; i++ {
+		if _, err := chain(context.Background(), req); err != nil {
+			t.Fatalf("attempt %d: unexpected error before breaker trips: %v", i, err)
+		}
+	}
+
+	if _, err := chain(context.Background(), req); err != errCircuitOpen {
+		t.Fatalf("expected errCircuitOpen once maxConsecutiveFailures is reached, got %v", err)
+	}
+
+	time.Sleep(openDuration * 2)
+
+	succeeding := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return newTestResponse(http.StatusOK), nil
+	}
+	chain = breaker(context.Background(), "fn-1", succeeding)
+	if _, err := chain(context.Background(), req); err != nil {
+		t.Fatalf("expected breaker to allow a call again once openDuration elapses: %v", err)
+	}
+}
+
+func TestCircuitBreakerInterceptorIsolatesByFunctionID(t *testing.T) {
+	const openDuration = time.Minute
+	breaker := CircuitBreakerInterceptor(1, openDuration)
+	req, _ := http.NewRequest("POST", "http://example.invalid", nil)
+
+	failing := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return newTestResponse(http.StatusBadGateway), nil
+	}
+	if _, err := breaker(context.Background(), "fn-1", failing)(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error tripping fn-1's breaker: %v", err)
+	}
+
+	if _, err := breaker(context.Background(), "fn-1", failing)(context.Background(), req); err != errCircuitOpen {
+		t.Fatalf("expected fn-1's breaker to be open, got %v", err)
+	}
+
+	succeeding := func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return newTestResponse(http.StatusOK), nil
+	}
+	if _, err := breaker(context.Background(), "fn-2", succeeding)(context.Background(), req); err != nil {
+		t.Fatalf("fn-2's breaker should be unaffected by fn-1 tripping, got %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package actor
+
+import (
+	"fmt"
+
+	"github.com/fnproject/flow/model"
+)
+
+// JournalKey deterministically identifies a single stage invocation attempt.
+type JournalKey struct {
+	FlowID  string
+	StageID string
+	Attempt int32
+}
+
+func (k JournalKey) String() string {
+	return fmt.Sprintf("%s/%s/%d", k.FlowID, k.StageID, k.Attempt)
+}
+
+// journalKeyForStage derives the JournalKey for msg's current attempt.
+func journalKeyForStage(msg *model.InvokeStageRequest) JournalKey {
+	return JournalKey{FlowID: msg.GetFlowId(), StageID: msg.GetStageId(), Attempt: msg.GetAttempt()}
+}
+
+// Journal records the outcome of a stage invocation so it can be replayed
+// without re-invoking the function, backed by the same storage the graph
+// engine uses for its own events.
+type Journal interface {
+	// Lookup returns the previously recorded response for key, if any.
+	Lookup(key JournalKey) (*model.FaasInvocationResponse, bool)
+	// Record durably stores resp as the outcome of key, including any blob IDs
+	// embedded in its result datum, so a later Lookup resolves reads too.
+	Record(key JournalKey, resp *model.FaasInvocationResponse) error
+}
+
+// noopJournal is the default Journal: it never has a hit and silently drops
+// records.
+type noopJournal struct{}
+
+func (noopJournal) Lookup(JournalKey) (*model.FaasInvocationResponse, bool) { return nil, false }
+func (noopJournal) Record(JournalKey, *model.FaasInvocationResponse) error  { return nil }
@@ -0,0 +1,73 @@
+package actor
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestExecutor() *graphExecutor {
+	return &graphExecutor{journalLocks: make(map[JournalKey]*journalMutex)}
+}
+
+func TestLockJournalKeySerializesSameKey(t *testing.T) {
+	exec := newTestExecutor()
+	key := JournalKey{FlowID: "f", StageID: "s", Attempt: 1}
+
+	var mu sync.Mutex
+	held, overlapped := false, false
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := exec.lockJournalKey(key)
+			defer unlock()
+
+			mu.Lock()
+			if held {
+				overlapped = true
+			}
+			held = true
+			mu.Unlock()
+
+			mu.Lock()
+			held = false
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if overlapped {
+		t.Fatal("lockJournalKey let two goroutines hold the same key concurrently")
+	}
+	if len(exec.journalLocks) != 0 {
+		t.Fatalf("journalLocks not evicted once all waiters drained: %d entries remain", len(exec.journalLocks))
+	}
+}
+
+func TestLockJournalKeyDoesNotSerializeDifferentKeys(t *testing.T) {
+	exec := newTestExecutor()
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+
+	for _, flowID := range []string{"a", "b"} {
+		flowID := flowID
+		go func() {
+			unlock := exec.lockJournalKey(JournalKey{FlowID: flowID})
+			started <- struct{}{}
+			<-release
+			unlock()
+		}()
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("locking distinct keys blocked on each other")
+		}
+	}
+	close(release)
+}
@@ -0,0 +1,85 @@
+package actor
+
+import "time"
+
+// ExecutorOption configures optional behavior on a graphExecutor created via
+// NewExecutor / NewExecutorWithTracer.
+type ExecutorOption func(*graphExecutor)
+
+// WithInterceptors applies interceptors around each outbound FaaS call,
+// outermost first.
+func WithInterceptors(interceptors ...Interceptor) ExecutorOption {
+	return func(exec *graphExecutor) {
+		exec.interceptors = interceptors
+	}
+}
+
+// WithStageTimeout overrides the default per-stage context deadline.
+func WithStageTimeout(timeout time.Duration) ExecutorOption {
+	return func(exec *graphExecutor) {
+		exec.stageTimeout = timeout
+	}
+}
+
+// WithUploadChunkSize overrides the default chunk size used when streaming a
+// FaaS response into blob storage via a chunked upload session.
+func WithUploadChunkSize(bytes int) ExecutorOption {
+	return func(exec *graphExecutor) {
+		exec.uploadChunkSize = bytes
+	}
+}
+
+// WithPoolSize overrides the number of worker goroutines the executor uses to
+// process inbound invocation requests.
+func WithPoolSize(size int) ExecutorOption {
+	return func(exec *graphExecutor) {
+		exec.poolSize = size
+	}
+}
+
+// WithQueueDepth overrides how many inbound invocation requests may be
+// buffered waiting for a free worker before Receive starts rejecting them.
+func WithQueueDepth(depth int) ExecutorOption {
+	return func(exec *graphExecutor) {
+		exec.queueDepth = depth
+	}
+}
+
+// WithHTTPClientFactory overrides how each worker's HTTP client is constructed.
+// It is called once per worker goroutine at pool startup.
+func WithHTTPClientFactory(newClient func() httpClient) ExecutorOption {
+	return func(exec *graphExecutor) {
+		exec.newWorkerClient = newClient
+	}
+}
+
+// WithCodecs enables codecs for stage invocation, sent on the Accept header
+// as a content-negotiation hint, and uses preferred to encode outbound
+// requests by default (see WithFunctionCodec for per-function overrides).
+func WithCodecs(preferred StageCodec, codecs ...StageCodec) ExecutorOption {
+	return func(exec *graphExecutor) {
+		exec.preferredCodec = preferred
+		exec.codecs = codecs
+	}
+}
+
+// WithFunctionCodec opts a specific function ID into a codec other than the
+// executor's preferred one, e.g. to move a single heavy Fn runtime onto the
+// binary protobuf path without changing the default for everyone else.
+func WithFunctionCodec(functionID string, codec StageCodec) ExecutorOption {
+	return func(exec *graphExecutor) {
+		if exec.functionCodecOverrides == nil {
+			exec.functionCodecOverrides = make(map[string]StageCodec)
+		}
+		exec.functionCodecOverrides[functionID] = codec
+	}
+}
+
+// WithJournal makes stage invocations replay-safe: before calling the
+// function, the executor consults journal for the current attempt's
+// recorded outcome, and records every outcome it does produce.
+func WithJournal(journal Journal) ExecutorOption {
+	return func(exec *graphExecutor) {
+		exec.journal = journal
+	}
+}
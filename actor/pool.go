@@ -0,0 +1,126 @@
+package actor
+
+import (
+	"sync/atomic"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+	"github.com/fnproject/flow/model"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultPoolSize and defaultQueueDepth bound the executor's worker pool when
+// NewExecutor is not given WithPoolSize/WithQueueDepth explicitly.
+const (
+	defaultPoolSize   = 32
+	defaultQueueDepth = 256
+)
+
+var (
+	executorQueueDepthMetric = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "flow_executor_queue_depth",
+		Help: "Number of inbound invocation requests waiting in the executor's work queue.",
+	})
+	executorWorkerUtilizationMetric = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "flow_executor_worker_utilization",
+		Help: "Fraction of the executor's worker pool currently processing a request.",
+	})
+	executorRejectedRequestsMetric = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "flow_executor_rejected_requests_total",
+		Help: "Number of invocation requests rejected because the executor's work queue was full.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(executorQueueDepthMetric, executorWorkerUtilizationMetric, executorRejectedRequestsMetric)
+}
+
+// poolTask is one unit of work handed from Receive to a worker goroutine.
+type poolTask struct {
+	message interface{}
+	sender  *actor.PID
+}
+
+// dispatch hands msg to the worker pool, replying immediately with an
+// overloaded result if the queue is full rather than blocking the actor
+// mailbox. queueMu guards the stopped check and the send as one step so a
+// concurrent stopWorkerPool can't close exec.queue between them.
+func (exec *graphExecutor) dispatch(msg interface{}, sender *actor.PID) {
+	exec.queueMu.Lock()
+	defer exec.queueMu.Unlock()
+	if exec.stopped {
+		executorRejectedRequestsMetric.Inc()
+		sender.Tell(overloadedResponse(msg))
+		return
+	}
+	select {
+	case exec.queue <- poolTask{message: msg, sender: sender}:
+		executorQueueDepthMetric.Set(float64(len(exec.queue)))
+	default:
+		executorRejectedRequestsMetric.Inc()
+		sender.Tell(overloadedResponse(msg))
+	}
+}
+
+// startWorkerPool creates the bounded work queue and launches exec.poolSize
+// worker goroutines, each with its own HTTP client so idle connections are
+// reused per worker rather than shared across the whole pool.
+func (exec *graphExecutor) startWorkerPool() {
+	exec.queue = make(chan poolTask, exec.queueDepth)
+	for i := 0; i < exec.poolSize; i++ {
+		exec.wg.Add(1)
+		go exec.runWorker(exec.newWorkerClient())
+	}
+}
+
+// stopWorkerPool stops accepting new work, cancels all outstanding request
+// contexts and waits for in-flight workers to unwind. Requests still
+// in-flight fail fast on the cancelled context (see handleInvokeStage's
+// rootCtx check) and are reported to their senders as a stage_timeout.
+func (exec *graphExecutor) stopWorkerPool() {
+	exec.queueMu.Lock()
+	exec.stopped = true
+	close(exec.queue)
+	exec.queueMu.Unlock()
+	exec.rootCancel()
+	exec.wg.Wait()
+}
+
+func (exec *graphExecutor) runWorker(client httpClient) {
+	defer exec.wg.Done()
+	for task := range exec.queue {
+		exec.setBusy(1)
+		executorQueueDepthMetric.Set(float64(len(exec.queue)))
+
+		switch msg := task.message.(type) {
+		case *model.InvokeStageRequest:
+			task.sender.Tell(exec.handleInvokeStage(msg, client))
+		case *model.InvokeFunctionRequest:
+			task.sender.Tell(exec.handleInvokeFunction(msg, client))
+		}
+
+		exec.setBusy(-1)
+	}
+}
+
+func (exec *graphExecutor) setBusy(delta int32) {
+	busy := atomic.AddInt32(&exec.busyCount, delta)
+	executorWorkerUtilizationMetric.Set(float64(busy) / float64(exec.poolSize))
+}
+
+// overloadedResponse builds the stage_failed/function_invoke_failed result
+// sent back to callers when the work queue is full.
+func overloadedResponse(message interface{}) *model.FaasInvocationResponse {
+	switch msg := message.(type) {
+	case *model.InvokeStageRequest:
+		return stageFailed(msg, model.ErrorDatumType_stage_failed, "executor overloaded: work queue is full", "")
+	case *model.InvokeFunctionRequest:
+		return &model.FaasInvocationResponse{
+			FlowId:     msg.FlowId,
+			StageId:    msg.StageId,
+			FunctionId: msg.FunctionId,
+			Result:     model.NewInternalErrorResult(model.ErrorDatumType_function_invoke_failed, "executor overloaded: work queue is full"),
+		}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,66 @@
+package actor
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/AsynkronIT/protoactor-go/actor"
+)
+
+// poolTestMsg is a placeholder pool task message: runWorker's type switch
+// doesn't match it, so a worker never replies to its sender and dispatching
+// it exercises the pool's queue/shutdown plumbing without a real FaaS
+// backend. A rejected dispatch still replies, so that case needs a sender PID.
+type poolTestMsg struct{}
+
+func newTestPoolExecutor(poolSize, queueDepth int) *graphExecutor {
+	rootCtx, rootCancel := context.WithCancel(context.Background())
+	return &graphExecutor{
+		poolSize:        poolSize,
+		queueDepth:      queueDepth,
+		newWorkerClient: func() httpClient { return &http.Client{} },
+		rootCtx:         rootCtx,
+		rootCancel:      rootCancel,
+	}
+}
+
+func TestDispatchAfterStopIsRejectedNotPanicked(t *testing.T) {
+	exec := newTestPoolExecutor(1, 4)
+	exec.startWorkerPool()
+	exec.stopWorkerPool()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		exec.dispatch(&poolTestMsg{}, actor.NewLocalPID("pool-test-sender"))
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("dispatch after stopWorkerPool did not return (likely blocked sending on a closed channel)")
+	}
+}
+
+func TestStopWorkerPoolDrainsQueuedWork(t *testing.T) {
+	exec := newTestPoolExecutor(2, 4)
+	exec.startWorkerPool()
+
+	for i := 0; i < 4; i++ {
+		exec.dispatch(&poolTestMsg{}, nil)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		exec.stopWorkerPool()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stopWorkerPool did not return once the queue drained")
+	}
+}